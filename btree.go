@@ -1,5 +1,7 @@
 package btree
 
+import "sync"
+
 // Item represents a single object in the tree
 type Item interface {
 	// Less tests whether the current item is less than the given argument.
@@ -13,35 +15,57 @@ type Item interface {
 	Less(than Item, ctx interface{}) bool
 }
 
+// LessFunc determines how items are ordered in a BTreeG. It reports whether
+// a is strictly less than b, using the same strict-weak-ordering contract as
+// Item.Less, minus the ctx plumbing: callers that need external context
+// should close over it when building the LessFunc.
+type LessFunc[T any] func(a, b T) bool
+
 const DefaultFreeListSize = 32
 
-// FreeList represents a free list of btree nodes. By default each
-// BTree has its own FreeList, but multiple BTree can  shere the same
-// FreeList.
-// Two Btree using the same freelist are not safe for concurrent write access.
-type FreeList struct {
-	freelist []*node
+// FreeListG represents a free list of btree nodes. By default each BTreeG
+// has its own FreeListG, but multiple BTreeG can share the same FreeListG.
+// A shared FreeListG is safe for concurrent use by multiple BTreeG, each
+// mutated from its own goroutine: mu guards newNode/freeNode.
+type FreeListG[T any] struct {
+	mu       sync.Mutex
+	freelist []*node[T]
 }
 
-// NewFreeList creates a new list.
+// NewFreeListG creates a new list.
 // size is the maximum of the returned freelist.
-func NewFreeList(size int) *FreeList {
-	return &FreeList{freelist: make([]*node, 0, size)}
+func NewFreeListG[T any](size int) *FreeListG[T] {
+	return &FreeListG[T]{freelist: make([]*node[T], 0, size)}
 }
 
-func (f *FreeList) newNode() (n *node) {
+func (f *FreeListG[T]) newNode() (n *node[T]) {
+	f.mu.Lock()
 	index := len(f.freelist) - 1
 	if index < 0 {
-		return new(node)
+		f.mu.Unlock()
+		return new(node[T])
 	}
 	f.freelist, n = f.freelist[:index], f.freelist[index]
+	f.mu.Unlock()
 	return
 }
 
-func (f *FreeList) freeNode(n *node) {
+func (f *FreeListG[T]) freeNode(n *node[T]) {
+	f.mu.Lock()
 	if len(f.freelist) < cap(f.freelist) {
 		f.freelist = append(f.freelist, n)
 	}
+	f.mu.Unlock()
+}
+
+// FreeList is the Item-based free list, kept so callers that have not moved
+// to the generic BTreeG API can keep using FreeList/NewFreeList unchanged.
+type FreeList = FreeListG[Item]
+
+// NewFreeList creates a new list.
+// size is the maximum of the returned freelist.
+func NewFreeList(size int) *FreeList {
+	return NewFreeListG[Item](size)
 }
 
 // ItemIterator allows callers of Ascend* to iterate in-order over portions of
@@ -49,35 +73,259 @@ func (f *FreeList) freeNode(n *node) {
 // associated Ascend* function will immediately return.
 type ItemIterator func(i Item) bool
 
-// New creates a new B-Tree with the given degree.
+// ItemIteratorG is the generic form of ItemIterator, used by BTreeG's
+// Ascend*/Descend* family.
+type ItemIteratorG[T any] func(item T) bool
+
+// BTreeG is a generic B-Tree, ordered by a caller-supplied LessFunc rather
+// than the Item.Less/ctx pair that BTree uses. Comparisons call the LessFunc
+// directly, which avoids the interface call and boxing that BTree pays on
+// every insert/get.
 //
 // New(2), for example, will create a 2-3-4 tree (each node contains 1-3 items
 // and 2-4 children).
-// The ctx param is user-defined.
-func New(dgree int, ctx interface{}) *BTree {
-	return NewWithFreeList(degree, NewFreeList(DefaultFreeListSize), ctx)
+type BTreeG[T any] struct {
+	degree int
+	length int
+	root   *node[T]
+	less   LessFunc[T]
+	cow    *copyOnWriteContext[T]
 }
 
-// NewWithFreeList creates a new B-Tree that uses the given node free list.
-func NewWithFreeList(degree int, f *FreeList, ctx interface{}) *BTree {
+// copyOnWriteContext identifies the tree that currently owns a node and may
+// mutate it in place. A node is owned by the context whose pointer equals
+// node.cow; any other context must copy a node via mutableFor before
+// changing it. Clone hands the original tree and its clone distinct contexts
+// wrapping the same freelist, which is what makes the clone an O(1)
+// snapshot instead of an O(n) copy.
+type copyOnWriteContext[T any] struct {
+	freelist *FreeListG[T]
+}
+
+// NewG creates a new B-Tree with the given degree.
+//
+// NewG(2, less), for example, will create a 2-3-4 tree (each node contains
+// 1-3 items and 2-4 children).
+func NewG[T any](degree int, less LessFunc[T]) *BTreeG[T] {
+	return NewWithFreeListG(degree, NewFreeListG[T](DefaultFreeListSize), less)
+}
+
+// NewWithFreeListG creates a new B-Tree that uses the given node free list.
+func NewWithFreeListG[T any](degree int, f *FreeListG[T], less LessFunc[T]) *BTreeG[T] {
 	if degree <= 1 {
 		panic("bad degree")
 	}
+	return &BTreeG[T]{
+		degree: degree,
+		cow:    &copyOnWriteContext[T]{freelist: f},
+		less:   less,
+	}
+}
+
+// Clone returns a new tree that currently shares all of t's nodes, but is
+// logically independent: subsequent mutations to either tree copy only the
+// nodes they actually touch rather than the whole tree, so Clone is O(1).
+//
+// Callers must still synchronize their own concurrent use of t and the
+// returned tree the same way they would for any two unrelated trees; Clone
+// does not add locking.
+func (t *BTreeG[T]) Clone() *BTreeG[T] {
+	// Create two independent copy-on-write contexts, one for each of the
+	// trees that now exist (t and the clone we're about to return). Both
+	// still point at the same freelist, so node pooling stays shared, but
+	// node ownership diverges the instant either tree mutates anything.
+	cow1, cow2 := *t.cow, *t.cow
+	out := *t
+	t.cow = &cow1
+	out.cow = &cow2
+	return &out
+}
 
-	return &BTree{
-		degree:   degree,
-		freelist: f,
-		ctx:      ctx,
+func (t *BTreeG[T]) newNode() *node[T] {
+	return t.cow.newNode()
+}
+
+func (t *BTreeG[T]) freeNode(n *node[T]) {
+	t.cow.freeNode(n)
+}
+
+func (c *copyOnWriteContext[T]) newNode() (n *node[T]) {
+	n = c.freelist.newNode()
+	n.cow = c
+	return
+}
+
+func (c *copyOnWriteContext[T]) freeNode(n *node[T]) {
+	if n.cow == c {
+		// We own a unique reference to n, so we can free it. Truncate rather
+		// than nil out items/children so the freelist keeps reusing the
+		// backing arrays, while still dropping the references they held so
+		// those items/children can be garbage collected.
+		n.items.truncate(0)
+		n.children.truncate(0)
+		n.size = 0
+		n.cow = nil
+		c.freelist.freeNode(n)
+	}
+	// n is shared with some other tree, so leave it alone: freeing it here
+	// would pull a still-referenced node back into the pool.
+}
+
+func (t *BTreeG[T]) maxItems() int {
+	return t.degree*2 - 1
+}
+
+func (t *BTreeG[T]) minItems() int {
+	return t.degree - 1
+}
+
+// ReplaceOrInsert adds the given item to the tree. If an item in the tree
+// already equals the given one, it is removed from the tree and returned
+// along with true. Otherwise, the zero value of T and false are returned.
+func (t *BTreeG[T]) ReplaceOrInsert(item T) (_ T, _ bool) {
+	if t.root == nil {
+		t.root = t.newNode()
+		t.root.items = append(t.root.items, item)
+		t.root.size = 1
+		t.length++
+		return
+	}
+	t.root = t.root.mutableFor(t.cow)
+	if len(t.root.items) >= t.maxItems() {
+		item2, second := t.root.split(t.maxItems() / 2)
+		oldroot := t.root
+		t.root = t.newNode()
+		t.root.items = append(t.root.items, item2)
+		t.root.children = append(t.root.children, oldroot, second)
+		t.root.recalc()
+	}
+	out, outFound := t.root.insert(item, t.maxItems(), t.less)
+	if !outFound {
+		t.length++
+	}
+	return out, outFound
+}
+
+// Delete removes an item equal to the passed in item from the tree, returning
+// it along with true, or the zero value of T and false if it didn't exist.
+func (t *BTreeG[T]) Delete(item T) (T, bool) {
+	return t.deleteItem(item, removeItem)
+}
+
+// DeleteMin removes the smallest item in the tree and returns it along with
+// true, or the zero value of T and false if the tree is empty.
+func (t *BTreeG[T]) DeleteMin() (T, bool) {
+	var zero T
+	return t.deleteItem(zero, removeMin)
+}
+
+// DeleteMax removes the largest item in the tree and returns it along with
+// true, or the zero value of T and false if the tree is empty.
+func (t *BTreeG[T]) DeleteMax() (T, bool) {
+	var zero T
+	return t.deleteItem(zero, removeMax)
+}
+
+func (t *BTreeG[T]) deleteItem(item T, typ toRemove) (_ T, _ bool) {
+	if t.root == nil || len(t.root.items) == 0 {
+		return
+	}
+	t.root = t.root.mutableFor(t.cow)
+	out, outFound := t.root.remove(item, t.minItems(), typ, t.less)
+	if len(t.root.items) == 0 && len(t.root.children) > 0 {
+		oldroot := t.root
+		t.root = t.root.children[0]
+		t.freeNode(oldroot)
 	}
+	if outFound {
+		t.length--
+	}
+	return out, outFound
 }
 
-// item stores items in a node.
-type items []Item
+// Get looks for the key item in the tree, returning it along with true if it
+// is found, or the zero value of T and false otherwise.
+func (t *BTreeG[T]) Get(key T) (_ T, _ bool) {
+	if t.root == nil {
+		return
+	}
+	return t.root.get(key, t.less)
+}
+
+// Has returns true if the given key is in the tree.
+func (t *BTreeG[T]) Has(item T) bool {
+	_, ok := t.Get(item)
+	return ok
+}
+
+// Min returns the smallest item in the tree, along with true. If the tree is
+// empty, it returns the zero value of T and false.
+func (t *BTreeG[T]) Min() (T, bool) {
+	return min(t.root)
+}
+
+// Max returns the largest item in the tree, along with true. If the tree is
+// empty, it returns the zero value of T and false.
+func (t *BTreeG[T]) Max() (T, bool) {
+	return max(t.root)
+}
+
+// Len returns the number of items currently in the tree.
+func (t *BTreeG[T]) Len() int {
+	return t.length
+}
+
+// At returns the i-th smallest item in the tree (zero-based). It returns the
+// zero value of T and false if i is out of range.
+func (t *BTreeG[T]) At(i int) (_ T, _ bool) {
+	if i < 0 || i >= t.length {
+		return
+	}
+	return t.root.at(i), true
+}
+
+// Rank returns the number of items in the tree strictly less than item.
+func (t *BTreeG[T]) Rank(item T) int {
+	if t.root == nil {
+		return 0
+	}
+	return t.root.rank(item, t.less)
+}
+
+// DeleteAt removes and returns the i-th smallest item in the tree
+// (zero-based). It returns the zero value of T and false if i is out of
+// range.
+func (t *BTreeG[T]) DeleteAt(i int) (_ T, _ bool) {
+	item, ok := t.At(i)
+	if !ok {
+		return
+	}
+	return t.Delete(item)
+}
+
+// Height returns the number of levels in the tree. An empty tree has height
+// 0.
+func (t *BTreeG[T]) Height() int {
+	h := 0
+	n := t.root
+	for n != nil && len(n.items) > 0 {
+		h++
+		if len(n.children) == 0 {
+			break
+		}
+		n = n.children[0]
+	}
+	return h
+}
+
+// items stores items in a node.
+type items[T any] []T
 
 // insertAt inserts a value into the given index, pushing all subsequent values
 // forward.
-func (s *items) insertAt(index int, item Item) {
-	*s = append(*s, nil)
+func (s *items[T]) insertAt(index int, item T) {
+	var zero T
+	*s = append(*s, zero)
 	if index < len(*s) {
 		copy((*s)[index+1:], (*s)[index:])
 	}
@@ -86,48 +334,70 @@ func (s *items) insertAt(index int, item Item) {
 
 // removeAt removes a value at a given index, pulling all subsequent values
 // back.
-func (s *items) removeAt(index int) Item {
+func (s *items[T]) removeAt(index int) T {
 	item := (*s)[index]
 	copy((*s)[index:], (*s)[index+1:])
-	(*s)[len(*s)-1] = nil
+	var zero T
+	(*s)[len(*s)-1] = zero
 	*s = (*s)[:len(*s)-1]
 	return item
 }
 
 // pop removes and returns the last element in the list.
-func (s *items) pop() (out Item) {
+func (s *items[T]) pop() (out T) {
 	index := len(*s) - 1
 	out = (*s)[index]
-	(*s)[index] = nil
+	var zero T
+	(*s)[index] = zero
 	*s = (*s)[:index]
 	return
 }
 
+// truncate discards all items from index onward, zeroing the freed slots
+// first so a *node sitting in a FreeListG doesn't keep otherwise-unreachable
+// items alive through its backing array.
+//
+// The non-generic btree cleared a tail by copying over it from a shared,
+// lazily-grown nilItems sentinel slice, which let copy do the zeroing in one
+// call. That doesn't translate to items[T]: the sentinel would need to be of
+// type items[T] for whichever T the caller instantiated, and a package-level
+// var can't carry an unbound type parameter. Zeroing the tail in a loop is
+// the straightforward generic equivalent; it's O(n) either way; nothing
+// calls truncate with a large enough tail for the loop overhead to matter.
+func (s *items[T]) truncate(index int) {
+	var toClear items[T]
+	*s, toClear = (*s)[:index], (*s)[index:]
+	var zero T
+	for i := range toClear {
+		toClear[i] = zero
+	}
+}
+
 // find returns the index where the given item should be inserted into this
 // list. `found` is true if the item already exists in the list at the given
 // index.
-func (s items) find(item Item, ctx interface{}) (index int, found bool) {
+func (s items[T]) find(item T, less LessFunc[T]) (index int, found bool) {
 	i, j := 0, len(s)
 	for i < j {
 		h := i + (j-i)/2
-		if !item.Less(s[h], ctx) {
+		if !less(item, s[h]) {
 			i = h + 1
 		} else {
 			j = h
 		}
 	}
-	if i > 0 && !s[i-1].Less(item, ctx) {
+	if i > 0 && !less(s[i-1], item) {
 		return i - 1, true
 	}
 	return i, false
 }
 
 // children stores child nodes in a node.
-type children []*node
+type children[T any] []*node[T]
 
 // insertAt inserts a value into the given index, pushing all subsequent values
 // forward.
-func (s *children) insertAt(index int, n *node) {
+func (s *children[T]) insertAt(index int, n *node[T]) {
 	*s = append(*s, nil)
 	if index < len(*s) {
 		copy((*s)[index+1:], (*s)[index:])
@@ -137,16 +407,16 @@ func (s *children) insertAt(index int, n *node) {
 
 // removeAt removes a value at a given index, pulling all subsequent values
 // back.
-func (s *children) removeAt(index int) *node {
+func (s *children[T]) removeAt(index int) *node[T] {
 	n := (*s)[index]
-	copy((**s)[index:], (*s)[index+1:])
+	copy((*s)[index:], (*s)[index+1:])
 	(*s)[len(*s)-1] = nil
 	*s = (*s)[:len(*s)-1]
 	return n
 }
 
 // pop removes and returns the last element in the list.
-func (s *children) pop() (out *node) {
+func (s *children[T]) pop() (out *node[T]) {
 	index := len(*s) - 1
 	out = (*s)[index]
 	(*s)[index] = nil
@@ -154,112 +424,221 @@ func (s *children) pop() (out *node) {
 	return
 }
 
-// node is an internal node in a tree
+// truncate discards all children from index onward, nilling the freed slots
+// first so a *node sitting in a FreeListG doesn't keep otherwise-unreachable
+// children alive through its backing array. See the comment on
+// items.truncate for why this loops instead of copying from a shared nil
+// sentinel.
+func (s *children[T]) truncate(index int) {
+	var toClear children[T]
+	*s, toClear = (*s)[:index], (*s)[index:]
+	for i := range toClear {
+		toClear[i] = nil
+	}
+}
+
+// node is an internal node in a tree.
 //
 // It must at all times maintain the invariant that either
 //    * len(children) == 0, len(items) unconstrained
 //	  * len(children) == len(items) + 1
-type node struct {
-	items    items
-	children children
-	t        *BTree
+type node[T any] struct {
+	items    items[T]
+	children children[T]
+	// size is the total number of items in the subtree rooted at this node,
+	// i.e. len(items) plus the size of every child. It is kept up to date by
+	// insert/split/remove/growChildAndRemove so At/Rank/DeleteAt can descend
+	// in O(log n) instead of walking the tree in order.
+	size int
+	cow  *copyOnWriteContext[T]
+}
+
+// recalc recomputes n.size from n's own items and its immediate children's
+// already-correct cached sizes. It does not recurse into grandchildren, so
+// it is O(degree), not O(n).
+func (n *node[T]) recalc() {
+	n.size = len(n.items)
+	for _, c := range n.children {
+		n.size += c.size
+	}
+}
+
+// mutableFor returns a node that n's owner may modify in place: n itself if
+// it's already owned by cow, otherwise a copy of n's items and children (but
+// not their children, which stay shared until they too are copied) freshly
+// owned by cow.
+func (n *node[T]) mutableFor(cow *copyOnWriteContext[T]) *node[T] {
+	if n.cow == cow {
+		return n
+	}
+	out := cow.newNode()
+	if cap(out.items) >= len(n.items) {
+		out.items = out.items[:len(n.items)]
+	} else {
+		out.items = make(items[T], len(n.items), cap(n.items))
+	}
+	copy(out.items, n.items)
+
+	if cap(out.children) >= len(n.children) {
+		out.children = out.children[:len(n.children)]
+	} else {
+		out.children = make(children[T], len(n.children), cap(n.children))
+	}
+	copy(out.children, n.children)
+
+	out.size = n.size
+	return out
+}
+
+// mutableChild returns a mutable version of the i'th child, copying it into
+// n's copy-on-write context if it isn't already owned by it, and recording
+// the (possibly new) child back into n.children.
+func (n *node[T]) mutableChild(i int) *node[T] {
+	c := n.children[i].mutableFor(n.cow)
+	n.children[i] = c
+	return c
 }
 
 // split splits the given node at the given index. The current node shirks,
 // and this function returns the iterm that existed at that index and a new node
 // containing all items/children after it.
-func (n *node) split(i int) (Item, *node) {
+func (n *node[T]) split(i int) (T, *node[T]) {
 	item := n.items[i]
-	next := n.t.newNode()
+	next := n.cow.newNode()
 	next.items = append(next.items, n.items[i+1:]...)
-	n.items = n.items[:i]
+	n.items.truncate(i)
 	if len(n.children) > 0 {
 		next.children = append(next.children, n.children[i+1:]...)
-		n.children = n.children[:i+1]
+		n.children.truncate(i + 1)
 	}
+	n.recalc()
+	next.recalc()
 	return item, next
 }
 
 // maybeSplitChild checks if a child should be split, and if so splits it.
 // Returns whether or not a split occurred.
-func (n *node) maybeSplitChild(i, maxItems int) bool {
+func (n *node[T]) maybeSplitChild(i, maxItems int) bool {
 	if len(n.children[i].items) < maxItems {
 		return false
 	}
-	first := n.children[i]
+	first := n.mutableChild(i)
 	item, second := first.split(maxItems / 2)
 	n.items.insertAt(i, item)
 	n.children.insertAt(i+1, second)
+	n.recalc()
 	return true
 }
 
-// insert inserts an item into the subtree rooted at this node. making sure
+// insert inserts an item into the subtree rooted at this node, making sure
 // no nodes in the subtree exceed maxItems items. Should an equivalent item be
-// found/replaced by insert, it will be returned.
-func (n *node) insert(item Item, maxItems int, ctx interface{}) Item {
-	i, found := n.items.find(item, ctx)
+// found/replaced by insert, it will be returned along with true.
+func (n *node[T]) insert(item T, maxItems int, less LessFunc[T]) (_ T, _ bool) {
+	i, found := n.items.find(item, less)
 	if found {
 		out := n.items[i]
 		n.items[i] = item
-		return out
+		return out, true
 	}
 	if len(n.children) == 0 {
 		n.items.insertAt(i, item)
-		return nil
+		n.size++
+		return
 	}
 	if n.maybeSplitChild(i, maxItems) {
 		inTree := n.items[i]
 		switch {
-		case item.Less(inTree, ctx):
+		case less(item, inTree):
 			// no change, we want first split node
-		case inTree.Less(item, ctx):
+		case less(inTree, item):
 			i++ // we want second split node
 		default:
 			out := n.items[i]
 			n.items[i] = item
-			return out
+			return out, true
 		}
 	}
-	return n.children[i].insert(item, maxItems, ctx)
+	out, outFound := n.mutableChild(i).insert(item, maxItems, less)
+	if !outFound {
+		n.size++
+	}
+	return out, outFound
 }
 
-// get finds the given key in the subtree and returns it.
-func (n *node) get(key Item, ctx interface{}) Item {
-	i, found := n.items.find(key, ctx)
-	if found {
+// at returns the i-th smallest item (zero-based) in the subtree rooted at n.
+func (n *node[T]) at(i int) T {
+	if len(n.children) == 0 {
 		return n.items[i]
+	}
+	for k, c := range n.children {
+		switch {
+		case i < c.size:
+			return c.at(i)
+		case i == c.size:
+			return n.items[k]
+		default:
+			i -= c.size + 1
+		}
+	}
+	panic("btree: index out of range")
+}
+
+// rank returns the number of items in the subtree rooted at n that are
+// strictly less than item.
+func (n *node[T]) rank(item T, less LessFunc[T]) int {
+	i, found := n.items.find(item, less)
+	if len(n.children) == 0 {
+		return i
+	}
+	rank := 0
+	for k := 0; k < i; k++ {
+		rank += n.children[k].size + 1
+	}
+	if found {
+		return rank + n.children[i].size
+	}
+	return rank + n.children[i].rank(item, less)
+}
+
+// get finds the given key in the subtree and returns it, along with true.
+func (n *node[T]) get(key T, less LessFunc[T]) (_ T, _ bool) {
+	i, found := n.items.find(key, less)
+	if found {
+		return n.items[i], true
 	} else if len(n.children) > 0 {
-		return n.children[i].get(key, ctx)
+		return n.children[i].get(key, less)
 	}
-	return nil
+	return
 }
 
-// min returns the first item in the subtree.
-func min(n *node) Item {
+// min returns the first item in the subtree, along with true. If the subtree
+// is empty, it returns the zero value of T and false.
+func min[T any](n *node[T]) (_ T, _ bool) {
 	if n == nil {
-		return nil
+		return
 	}
 	for len(n.children) > 0 {
 		n = n.children[0]
 	}
 	if len(n.items) == 0 {
-		return nil
+		return
 	}
-	return n.items[0]
+	return n.items[0], true
 }
 
-// max returns the last item in the subtree.
-func max(n *node) Item {
+// max returns the last item in the subtree, along with true. If the subtree
+// is empty, it returns the zero value of T and false.
+func max[T any](n *node[T]) (_ T, _ bool) {
 	if n == nil {
-		return nil
+		return
 	}
 	for len(n.children) > 0 {
-		n = n.children[len(children)-1]
+		n = n.children[len(n.children)-1]
 	}
 	if len(n.items) == 0 {
-		return nil
+		return
 	}
-	return n.items[len(n.items)-1]
+	return n.items[len(n.items)-1], true
 }
 
 // toRemove deitals what item to remove in a node.remove call
@@ -272,108 +651,234 @@ const (
 )
 
 // remove removes an item from the subtree rooted at this node.
-func (n *node) remove(item Item, minItems int, typ toRemove, ctx interface{}) Item {
+func (n *node[T]) remove(item T, minItems int, typ toRemove, less LessFunc[T]) (_ T, _ bool) {
 	var i int
 	var found bool
 	switch typ {
 	case removeMax:
 		if len(n.children) == 0 {
-			return n.items.pop()
+			n.size--
+			return n.items.pop(), true
 		}
 		i = len(n.items)
 	case removeMin:
 		if len(n.children) == 0 {
-			return n.items.removeAt(0)
+			n.size--
+			return n.items.removeAt(0), true
 		}
 		i = 0
 	case removeItem:
-		i, found = n.items.find(item, ctx)
+		i, found = n.items.find(item, less)
 		if len(n.children) == 0 {
 			if found {
-				return n.items.removeAt(i)
+				n.size--
+				return n.items.removeAt(i), true
 			}
-			return nil
+			return
 		}
 	default:
 		panic("invalid type")
 	}
 
 	// If we get to here, we have children.
-	child := n.children[i]
-	if len(child.items) <= minItems {
-		return n.growChildAndRemove(i, item, minItems, typ, ctx)
+	if len(n.children[i].items) <= minItems {
+		return n.growChildAndRemove(i, item, minItems, typ, less)
 	}
-	// Either we had enough items to begin with, or we/ve done some
-	// merging/stealing, becuase we've got enough now and we're ready to return
-	// stuff
+	// Either we had enough items to begin with, or we've done some
+	// merging/stealing, because we've got enough now and we're ready to return
+	// stuff.
+	child := n.mutableChild(i)
 	if found {
-		// The item exitsts at index `i`. and the child we've selected can give us a
+		// The item exists at index `i`, and the child we've selected can give us a
 		// predecessor, since if we've gotten there it's got > minItems items in it.
 		out := n.items[i]
-		// We use our special-case 'remove' call with typ=maxItem to pull the
+		// We use our special-case 'remove' call with typ=removeMax to pull the
 		// predecessor of item i (the rightmost leaf of our immediate left child)
 		// and set it into where we pulled the item from.
-		n.items[i] = child.remove(nil, minItems, removeMax, ctx)
-		return out
+		n.items[i], _ = child.remove(item, minItems, removeMax, less)
+		n.size--
+		return out, true
 	}
-	// Final recursive cal. Once we're here, we know that the item isn't in this
+	// Final recursive call. Once we're here, we know that the item isn't in this
 	// node and that the child is big enough to remove from.
-	return child.remove(item, minItems, typ, ctx)
+	out, outFound := child.remove(item, minItems, typ, less)
+	if outFound {
+		n.size--
+	}
+	return out, outFound
 }
 
 // growChildAndRemove grows child `i` to make sure it's possible to remove an
-// item from it while keeping it at minItems, then cals remove to actually
+// item from it while keeping it at minItems, then calls remove to actually
 // remove it.
 //
-// Most documentation says we have to do two sets of special casting:
+// Most documentation says we have to do two sets of special casing:
 //  1) item is in this node
 //  2) item is in child
 // In both cases, we need to handle the two subcases:
 //	A) node has enough values that it can spare one
 //  B) node doesn't have enough values
 // For the latter, we have to check:
-//  a) left sibling has node to spare
-//	b) right sibling has node to spare
+//  a) left sibling has a value to spare
+//	b) right sibling has a value to spare
 //  c) we must merge
-// To simplify our code here, we handle case #1 and #2 the same:
-// If a node doesn't have enough items, we make sure it does (using a,b,c)
-// We then simply redoour remove call, and the second time
-// whether we're in case 1 or 2), we'll have enough items and can guarantee
-// that we hit case A.
-func (n *node) growChildAndRemove(i int, item Item, minItems int, typ toRemove, ctx interface{}) Item {
-	child := n.children[i]
+// To simplify our code here, we handle cases #1 and #2 the same:
+// if a node doesn't have enough items, we make sure it does (using a, b, c).
+// We then simply redo our remove call, and the second time (whether we're in
+// case 1 or 2), we'll have enough items and can guarantee that we hit case A.
+func (n *node[T]) growChildAndRemove(i int, item T, minItems int, typ toRemove, less LessFunc[T]) (T, bool) {
 	if i > 0 && len(n.children[i-1].items) > minItems {
 		// Steal from left child
-		stealFrom := n.children[i-1]
-		stolenItem := strealFrom.items.pop()
+		child := n.mutableChild(i)
+		stealFrom := n.mutableChild(i - 1)
+		stolenItem := stealFrom.items.pop()
 		child.items.insertAt(0, n.items[i-1])
 		n.items[i-1] = stolenItem
 		if len(stealFrom.children) > 0 {
 			child.children.insertAt(0, stealFrom.children.pop())
 		}
+		child.recalc()
+		stealFrom.recalc()
 	} else if i < len(n.items) && len(n.children[i+1].items) > minItems {
 		// Steal from right child
-		stealFrom := n.children[i+1]
+		child := n.mutableChild(i)
+		stealFrom := n.mutableChild(i + 1)
 		stolenItem := stealFrom.items.removeAt(0)
 		child.items = append(child.items, n.items[i])
 		n.items[i] = stolenItem
 		if len(stealFrom.children) > 0 {
 			child.children = append(child.children, stealFrom.children.removeAt(0))
 		}
+		child.recalc()
+		stealFrom.recalc()
 	} else {
 		if i >= len(n.items) {
 			i--
-			child = n.children[i]
 		}
+		child := n.mutableChild(i)
 		// Merge with right child
 		mergeItem := n.items.removeAt(i)
 		mergeChild := n.children.removeAt(i + 1)
-		child.items = append(child.items, mergerItem)
+		child.items = append(child.items, mergeItem)
 		child.items = append(child.items, mergeChild.items...)
 		child.children = append(child.children, mergeChild.children...)
-		n.t.freeNode(mergerChild)
+		child.recalc()
+		n.cow.freeNode(mergeChild)
 	}
-	return n.remove(item, minItems, typ, ctx)
+	return n.remove(item, minItems, typ, less)
+}
+
+// BTree is an Item-based B-Tree, kept around as a thin wrapper over
+// BTreeG[Item] for callers that have not moved to the generic API. All the
+// actual tree logic lives in BTreeG; BTree only adapts Item's
+// Less(than Item, ctx interface{}) bool into a LessFunc[Item] closing over
+// ctx, and unwraps the (value, found) pairs BTreeG returns back into the
+// nil-means-absent Item convention this API has always used.
+type BTree struct {
+	bt *BTreeG[Item]
+}
+
+// New creates a new B-Tree with the given degree.
+//
+// New(2), for example, will create a 2-3-4 tree (each node contains 1-3 items
+// and 2-4 children).
+// The ctx param is user-defined.
+func New(degree int, ctx interface{}) *BTree {
+	return NewWithFreeList(degree, NewFreeList(DefaultFreeListSize), ctx)
+}
+
+// NewWithFreeList creates a new B-Tree that uses the given node free list.
+func NewWithFreeList(degree int, f *FreeList, ctx interface{}) *BTree {
+	less := func(a, b Item) bool { return a.Less(b, ctx) }
+	return &BTree{bt: NewWithFreeListG(degree, f, less)}
+}
+
+// ReplaceOrInsert adds the given item to the tree. If an item in the tree
+// already equals the given one, it is removed from the tree and returned.
+// Otherwise, nil is returned.
+func (t *BTree) ReplaceOrInsert(item Item) Item {
+	out, _ := t.bt.ReplaceOrInsert(item)
+	return out
+}
+
+// Delete removes an item equal to the passed in item from the tree, returning
+// it, or nil if it didn't exist.
+func (t *BTree) Delete(item Item) Item {
+	out, _ := t.bt.Delete(item)
+	return out
+}
+
+// DeleteMin removes the smallest item in the tree and returns it, or nil if
+// the tree is empty.
+func (t *BTree) DeleteMin() Item {
+	out, _ := t.bt.DeleteMin()
+	return out
+}
+
+// DeleteMax removes the largest item in the tree and returns it, or nil if
+// the tree is empty.
+func (t *BTree) DeleteMax() Item {
+	out, _ := t.bt.DeleteMax()
+	return out
+}
+
+// Get looks for the key item in the tree, returning it. It returns nil if
+// unable to find that item.
+func (t *BTree) Get(key Item) Item {
+	out, _ := t.bt.Get(key)
+	return out
+}
+
+// Has returns true if the given key is in the tree.
+func (t *BTree) Has(item Item) bool {
+	return t.bt.Has(item)
+}
+
+// Min returns the smallest item in the tree, or nil if the tree is empty.
+func (t *BTree) Min() Item {
+	out, _ := t.bt.Min()
+	return out
+}
+
+// Max returns the largest item in the tree, or nil if the tree is empty.
+func (t *BTree) Max() Item {
+	out, _ := t.bt.Max()
+	return out
+}
+
+// Len returns the number of items currently in the tree.
+func (t *BTree) Len() int {
+	return t.bt.Len()
+}
+
+// At returns the i-th smallest item in the tree (zero-based), or nil if i is
+// out of range.
+func (t *BTree) At(i int) Item {
+	out, _ := t.bt.At(i)
+	return out
+}
+
+// Rank returns the number of items in the tree strictly less than item.
+func (t *BTree) Rank(item Item) int {
+	return t.bt.Rank(item)
+}
+
+// DeleteAt removes and returns the i-th smallest item in the tree
+// (zero-based), or nil if i is out of range.
+func (t *BTree) DeleteAt(i int) Item {
+	out, _ := t.bt.DeleteAt(i)
+	return out
+}
+
+// Height returns the number of levels in the tree.
+func (t *BTree) Height() int {
+	return t.bt.Height()
+}
+
+// Clone returns a new tree that currently shares all of t's nodes, but is
+// logically independent: see BTreeG.Clone for details.
+func (t *BTree) Clone() *BTree {
+	return &BTree{bt: t.bt.Clone()}
 }
 
 type direction int
@@ -383,10 +888,199 @@ const (
 	ascend  = direction(+1)
 )
 
-// iterate provides a simple method for iterating over elements in the tree
+// iterate provides a simple method for iterating over elements in the tree.
 //
-// When ascending, the `start` should be less than 'stop' and when descending,
+// When ascending, the `start` should be less than `stop` and when descending,
 // the `start` should be greater than `stop`. Setting `includeStart` to true
 // will force the iterator to include the first item when it equals `start`,
 // thus creating a 'greaterOrEqual' or 'lessThanEqual' rather than just a
-// 'greaterThan' or 'lessThan' queries
+// 'greaterThan' or 'lessThan' queries. A nil `start` or `stop` means
+// unbounded in that direction. `hit` tracks whether `start` has already been
+// passed, so nested calls don't each need to re-derive it.
+func (n *node[T]) iterate(dir direction, start, stop *T, includeStart, hit bool, less LessFunc[T], iter ItemIteratorG[T]) (bool, bool) {
+	var ok, found bool
+	var index int
+	switch dir {
+	case ascend:
+		if start != nil {
+			index, _ = n.items.find(*start, less)
+		}
+		for i := index; i < len(n.items); i++ {
+			if len(n.children) > 0 {
+				if hit, ok = n.children[i].iterate(dir, start, stop, includeStart, hit, less, iter); !ok {
+					return hit, false
+				}
+			}
+			if !includeStart && !hit && start != nil && !less(*start, n.items[i]) {
+				hit = true
+				continue
+			}
+			hit = true
+			if stop != nil && !less(n.items[i], *stop) {
+				return hit, false
+			}
+			if !iter(n.items[i]) {
+				return hit, false
+			}
+		}
+		if len(n.children) > 0 {
+			if hit, ok = n.children[len(n.children)-1].iterate(dir, start, stop, includeStart, hit, less, iter); !ok {
+				return hit, false
+			}
+		}
+	case descend:
+		if start != nil {
+			index, found = n.items.find(*start, less)
+			if !found {
+				index--
+			}
+		} else {
+			index = len(n.items) - 1
+		}
+		for i := index; i >= 0; i-- {
+			if start != nil && !less(n.items[i], *start) {
+				if !includeStart || hit || less(*start, n.items[i]) {
+					continue
+				}
+			}
+			if len(n.children) > 0 {
+				if hit, ok = n.children[i+1].iterate(dir, start, stop, includeStart, hit, less, iter); !ok {
+					return hit, false
+				}
+			}
+			if stop != nil && !less(*stop, n.items[i]) {
+				return hit, false
+			}
+			hit = true
+			if !iter(n.items[i]) {
+				return hit, false
+			}
+		}
+		if len(n.children) > 0 {
+			if hit, ok = n.children[0].iterate(dir, start, stop, includeStart, hit, less, iter); !ok {
+				return hit, false
+			}
+		}
+	}
+	return hit, true
+}
+
+// Ascend calls iter for every item in the tree in ascending order until iter
+// returns false.
+func (t *BTreeG[T]) Ascend(iter ItemIteratorG[T]) {
+	if t.root == nil {
+		return
+	}
+	t.root.iterate(ascend, nil, nil, true, false, t.less, iter)
+}
+
+// AscendRange calls iter for every item in the tree within the range
+// [greaterOrEqual, lessThan), in ascending order, until iter returns false.
+func (t *BTreeG[T]) AscendRange(greaterOrEqual, lessThan T, iter ItemIteratorG[T]) {
+	if t.root == nil {
+		return
+	}
+	t.root.iterate(ascend, &greaterOrEqual, &lessThan, true, false, t.less, iter)
+}
+
+// AscendLessThan calls iter for every item in the tree less than pivot, in
+// ascending order, until iter returns false.
+func (t *BTreeG[T]) AscendLessThan(pivot T, iter ItemIteratorG[T]) {
+	if t.root == nil {
+		return
+	}
+	t.root.iterate(ascend, nil, &pivot, false, false, t.less, iter)
+}
+
+// AscendGreaterOrEqual calls iter for every item in the tree greater than or
+// equal to pivot, in ascending order, until iter returns false.
+func (t *BTreeG[T]) AscendGreaterOrEqual(pivot T, iter ItemIteratorG[T]) {
+	if t.root == nil {
+		return
+	}
+	t.root.iterate(ascend, &pivot, nil, true, false, t.less, iter)
+}
+
+// Descend calls iter for every item in the tree in descending order until
+// iter returns false.
+func (t *BTreeG[T]) Descend(iter ItemIteratorG[T]) {
+	if t.root == nil {
+		return
+	}
+	t.root.iterate(descend, nil, nil, true, false, t.less, iter)
+}
+
+// DescendRange calls iter for every item in the tree within the range
+// [lessOrEqual, greaterThan), in descending order, until iter returns false.
+func (t *BTreeG[T]) DescendRange(lessOrEqual, greaterThan T, iter ItemIteratorG[T]) {
+	if t.root == nil {
+		return
+	}
+	t.root.iterate(descend, &lessOrEqual, &greaterThan, true, false, t.less, iter)
+}
+
+// DescendLessOrEqual calls iter for every item in the tree less than or
+// equal to pivot, in descending order, until iter returns false.
+func (t *BTreeG[T]) DescendLessOrEqual(pivot T, iter ItemIteratorG[T]) {
+	if t.root == nil {
+		return
+	}
+	t.root.iterate(descend, &pivot, nil, true, false, t.less, iter)
+}
+
+// DescendGreaterThan calls iter for every item in the tree greater than
+// pivot, in descending order, until iter returns false.
+func (t *BTreeG[T]) DescendGreaterThan(pivot T, iter ItemIteratorG[T]) {
+	if t.root == nil {
+		return
+	}
+	t.root.iterate(descend, nil, &pivot, false, false, t.less, iter)
+}
+
+// Ascend calls iter for every item in the tree in ascending order until iter
+// returns false.
+func (t *BTree) Ascend(iter ItemIterator) {
+	t.bt.Ascend(ItemIteratorG[Item](iter))
+}
+
+// AscendRange calls iter for every item in the tree within the range
+// [greaterOrEqual, lessThan), in ascending order, until iter returns false.
+func (t *BTree) AscendRange(greaterOrEqual, lessThan Item, iter ItemIterator) {
+	t.bt.AscendRange(greaterOrEqual, lessThan, ItemIteratorG[Item](iter))
+}
+
+// AscendLessThan calls iter for every item in the tree less than pivot, in
+// ascending order, until iter returns false.
+func (t *BTree) AscendLessThan(pivot Item, iter ItemIterator) {
+	t.bt.AscendLessThan(pivot, ItemIteratorG[Item](iter))
+}
+
+// AscendGreaterOrEqual calls iter for every item in the tree greater than or
+// equal to pivot, in ascending order, until iter returns false.
+func (t *BTree) AscendGreaterOrEqual(pivot Item, iter ItemIterator) {
+	t.bt.AscendGreaterOrEqual(pivot, ItemIteratorG[Item](iter))
+}
+
+// Descend calls iter for every item in the tree in descending order until
+// iter returns false.
+func (t *BTree) Descend(iter ItemIterator) {
+	t.bt.Descend(ItemIteratorG[Item](iter))
+}
+
+// DescendRange calls iter for every item in the tree within the range
+// [lessOrEqual, greaterThan), in descending order, until iter returns false.
+func (t *BTree) DescendRange(lessOrEqual, greaterThan Item, iter ItemIterator) {
+	t.bt.DescendRange(lessOrEqual, greaterThan, ItemIteratorG[Item](iter))
+}
+
+// DescendLessOrEqual calls iter for every item in the tree less than or
+// equal to pivot, in descending order, until iter returns false.
+func (t *BTree) DescendLessOrEqual(pivot Item, iter ItemIterator) {
+	t.bt.DescendLessOrEqual(pivot, ItemIteratorG[Item](iter))
+}
+
+// DescendGreaterThan calls iter for every item in the tree greater than
+// pivot, in descending order, until iter returns false.
+func (t *BTree) DescendGreaterThan(pivot Item, iter ItemIterator) {
+	t.bt.DescendGreaterThan(pivot, ItemIteratorG[Item](iter))
+}
@@ -0,0 +1,326 @@
+package btree
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type testItem int
+
+func (a testItem) Less(b Item, _ interface{}) bool {
+	return a < b.(testItem)
+}
+
+func collect(iter func(func(Item) bool)) []Item {
+	var got []Item
+	iter(func(item Item) bool {
+		got = append(got, item)
+		return true
+	})
+	return got
+}
+
+func testItems(vals ...int) []Item {
+	out := make([]Item, len(vals))
+	for i, v := range vals {
+		out[i] = testItem(v)
+	}
+	return out
+}
+
+func newFilledTree(n int) *BTree {
+	tr := New(2, nil)
+	for i := 0; i < n; i++ {
+		tr.ReplaceOrInsert(testItem(i))
+	}
+	return tr
+}
+
+func TestAscendDescendNilPivot(t *testing.T) {
+	tr := newFilledTree(10)
+
+	got := collect(func(iter func(Item) bool) { tr.Ascend(iter) })
+	if want := testItems(0, 1, 2, 3, 4, 5, 6, 7, 8, 9); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Ascend = %v, want %v", got, want)
+	}
+
+	got = collect(func(iter func(Item) bool) { tr.Descend(iter) })
+	if want := testItems(9, 8, 7, 6, 5, 4, 3, 2, 1, 0); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Descend = %v, want %v", got, want)
+	}
+}
+
+func TestAscendDescendRange(t *testing.T) {
+	tr := newFilledTree(10)
+
+	got := collect(func(iter func(Item) bool) { tr.AscendRange(testItem(3), testItem(7), iter) })
+	if want := testItems(3, 4, 5, 6); !reflect.DeepEqual(got, want) {
+		t.Fatalf("AscendRange = %v, want %v", got, want)
+	}
+
+	got = collect(func(iter func(Item) bool) { tr.DescendRange(testItem(7), testItem(3), iter) })
+	if want := testItems(7, 6, 5, 4); !reflect.DeepEqual(got, want) {
+		t.Fatalf("DescendRange = %v, want %v", got, want)
+	}
+
+	got = collect(func(iter func(Item) bool) { tr.AscendLessThan(testItem(3), iter) })
+	if want := testItems(0, 1, 2); !reflect.DeepEqual(got, want) {
+		t.Fatalf("AscendLessThan = %v, want %v", got, want)
+	}
+
+	got = collect(func(iter func(Item) bool) { tr.AscendGreaterOrEqual(testItem(7), iter) })
+	if want := testItems(7, 8, 9); !reflect.DeepEqual(got, want) {
+		t.Fatalf("AscendGreaterOrEqual = %v, want %v", got, want)
+	}
+
+	got = collect(func(iter func(Item) bool) { tr.DescendLessOrEqual(testItem(3), iter) })
+	if want := testItems(3, 2, 1, 0); !reflect.DeepEqual(got, want) {
+		t.Fatalf("DescendLessOrEqual = %v, want %v", got, want)
+	}
+
+	got = collect(func(iter func(Item) bool) { tr.DescendGreaterThan(testItem(7), iter) })
+	if want := testItems(9, 8); !reflect.DeepEqual(got, want) {
+		t.Fatalf("DescendGreaterThan = %v, want %v", got, want)
+	}
+}
+
+// TestInterleavedAscendDescend exercises interleaved forward and backward
+// iteration over the same tree to make sure the two directions don't share
+// any mutable state (e.g. the `hit` flag threaded through node.iterate).
+func TestInterleavedAscendDescend(t *testing.T) {
+	tr := newFilledTree(20)
+
+	gotUp := collect(func(iter func(Item) bool) { tr.AscendRange(testItem(5), testItem(15), iter) })
+	gotDown := collect(func(iter func(Item) bool) { tr.DescendRange(testItem(15), testItem(5), iter) })
+
+	if want := testItems(5, 6, 7, 8, 9, 10, 11, 12, 13, 14); !reflect.DeepEqual(gotUp, want) {
+		t.Fatalf("AscendRange = %v, want %v", gotUp, want)
+	}
+	if want := testItems(15, 14, 13, 12, 11, 10, 9, 8, 7, 6); !reflect.DeepEqual(gotDown, want) {
+		t.Fatalf("DescendRange = %v, want %v", gotDown, want)
+	}
+
+	var n int
+	tr.Ascend(func(item Item) bool {
+		n++
+		return n < 5
+	})
+	if n != 5 {
+		t.Fatalf("Ascend stopped after %d items, want 5", n)
+	}
+}
+
+// TestTruncateAfterSplitAndMerge forces many splits and merges (degree 2,
+// deleting every third item) and checks Get for both the survivors and the
+// removed keys. An off-by-one in items.truncate/children.truncate's index
+// math would either drop a survivor or leave a removed key reachable
+// through a stale tail slot; reusing the freed nodes via ReplaceOrInsert
+// afterward also exercises the freelist path truncate(0) feeds into.
+func TestTruncateAfterSplitAndMerge(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	tr := NewG(2, less)
+
+	const n = 300
+	for i := 0; i < n; i++ {
+		tr.ReplaceOrInsert(i)
+	}
+
+	removed := map[int]bool{}
+	for i := 0; i < n; i += 3 {
+		if _, ok := tr.Delete(i); !ok {
+			t.Fatalf("Delete(%d) = false, want true", i)
+		}
+		removed[i] = true
+	}
+
+	for i := 0; i < n; i++ {
+		_, ok := tr.Get(i)
+		switch {
+		case removed[i] && ok:
+			t.Fatalf("Get(%d) = true after delete, want false", i)
+		case !removed[i] && !ok:
+			t.Fatalf("Get(%d) = false, want true", i)
+		}
+	}
+	if want := n - len(removed); tr.Len() != want {
+		t.Fatalf("Len() = %d, want %d", tr.Len(), want)
+	}
+
+	for i := n; i < n+50; i++ {
+		tr.ReplaceOrInsert(i)
+	}
+	for i := n; i < n+50; i++ {
+		if _, ok := tr.Get(i); !ok {
+			t.Fatalf("Get(%d) = false, want true", i)
+		}
+	}
+}
+
+// TestCloneIndependence populates a tree, clones it, then mutates the
+// original and the clone in different, overlapping ways. Ownership in the
+// COW path is tracked by raw pointer identity (node.cow == the owning
+// context), so a bug here (a missing mutableFor, or the ownership check
+// backwards) wouldn't panic — it would silently corrupt whichever tree
+// shares the unmutated node. Checking both trees' full contents afterward
+// is what would catch that.
+func TestCloneIndependence(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	orig := NewG(2, less)
+	for i := 0; i < 100; i++ {
+		orig.ReplaceOrInsert(i)
+	}
+
+	clone := orig.Clone()
+
+	// Mutate the original and the clone in different, overlapping ways:
+	// delete the evens from orig, the odds from clone, and insert a batch
+	// only the clone should see.
+	for i := 0; i < 100; i += 2 {
+		orig.Delete(i)
+	}
+	for i := 1; i < 100; i += 2 {
+		clone.Delete(i)
+	}
+	for i := 100; i < 110; i++ {
+		clone.ReplaceOrInsert(i)
+	}
+
+	if got, want := orig.Len(), 50; got != want {
+		t.Fatalf("orig.Len() = %d, want %d", got, want)
+	}
+	if got, want := clone.Len(), 60; got != want {
+		t.Fatalf("clone.Len() = %d, want %d", got, want)
+	}
+
+	for i := 0; i < 100; i++ {
+		if _, ok := orig.Get(i); ok != (i%2 == 1) {
+			t.Fatalf("orig.Get(%d) = %v, want %v", i, ok, i%2 == 1)
+		}
+		if _, ok := clone.Get(i); ok != (i%2 == 0) {
+			t.Fatalf("clone.Get(%d) = %v, want %v", i, ok, i%2 == 0)
+		}
+	}
+	for i := 100; i < 110; i++ {
+		if _, ok := orig.Get(i); ok {
+			t.Fatalf("orig.Get(%d) = true, want false (clone-only insert leaked into orig)", i)
+		}
+		if _, ok := clone.Get(i); !ok {
+			t.Fatalf("clone.Get(%d) = false, want true", i)
+		}
+	}
+
+	// Cloning an already-mutated tree must stay independent too.
+	clone2 := orig.Clone()
+	clone2.ReplaceOrInsert(-1)
+	if _, ok := orig.Get(-1); ok {
+		t.Fatalf("orig.Get(-1) = true, want false (clone2 mutation leaked into orig)")
+	}
+	if _, ok := clone2.Get(-1); !ok {
+		t.Fatalf("clone2.Get(-1) = false, want true")
+	}
+}
+
+// TestAtRankDeleteAtProperty inserts a random permutation, then repeatedly
+// DeleteAts a random remaining index, checking At/Rank against a sorted
+// reference slice after every mutation. node.size has to stay correct
+// across every split/steal/merge path it takes, or At/Rank would silently
+// return a wrong item instead of failing loudly.
+func TestAtRankDeleteAtProperty(t *testing.T) {
+	const n = 200
+	rnd := rand.New(rand.NewSource(1))
+
+	tr := NewG(2, func(a, b int) bool { return a < b })
+	for _, v := range rnd.Perm(n) {
+		tr.ReplaceOrInsert(v)
+	}
+
+	ref := make([]int, n)
+	for i := range ref {
+		ref[i] = i
+	}
+
+	checkAtRank := func() {
+		t.Helper()
+		if got := tr.Len(); got != len(ref) {
+			t.Fatalf("Len() = %d, want %d", got, len(ref))
+		}
+		for i, want := range ref {
+			if got, ok := tr.At(i); !ok || got != want {
+				t.Fatalf("At(%d) = %v, %v, want %v, true", i, got, ok, want)
+			}
+			if rank := tr.Rank(want); rank != i {
+				t.Fatalf("Rank(%d) = %d, want %d", want, rank, i)
+			}
+		}
+		if _, ok := tr.At(len(ref)); ok {
+			t.Fatalf("At(%d) = ok, want not found", len(ref))
+		}
+	}
+	checkAtRank()
+
+	for _, idx := range rnd.Perm(n) {
+		i := idx % len(ref)
+		want := ref[i]
+		if got, ok := tr.DeleteAt(i); !ok || got != want {
+			t.Fatalf("DeleteAt(%d) = %v, %v, want %v, true", i, got, ok, want)
+		}
+		ref = append(ref[:i], ref[i+1:]...)
+		checkAtRank()
+	}
+}
+
+// TestFreeListGConcurrentSharing drives several BTreeGs that share a single
+// FreeListG from their own goroutines, so `go test -race` can catch a
+// regression in the mutex guarding FreeListG.newNode/freeNode.
+func TestFreeListGConcurrentSharing(t *testing.T) {
+	fl := NewFreeListG[int](DefaultFreeListSize)
+	less := func(a, b int) bool { return a < b }
+
+	const trees = 8
+	const opsPerTree = 200
+
+	var wg sync.WaitGroup
+	wg.Add(trees)
+	for g := 0; g < trees; g++ {
+		go func(seed int) {
+			defer wg.Done()
+			tr := NewWithFreeListG(2, fl, less)
+			for i := 0; i < opsPerTree; i++ {
+				v := seed*opsPerTree + i
+				tr.ReplaceOrInsert(v)
+				if i%3 == 0 {
+					tr.DeleteMin()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestBTreeGAscendDescend(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	tr := NewG(2, less)
+	for i := 0; i < 10; i++ {
+		tr.ReplaceOrInsert(i)
+	}
+
+	var got []int
+	tr.AscendRange(3, 7, func(v int) bool {
+		got = append(got, v)
+		return true
+	})
+	if want := []int{3, 4, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("AscendRange = %v, want %v", got, want)
+	}
+
+	got = nil
+	tr.DescendRange(7, 3, func(v int) bool {
+		got = append(got, v)
+		return true
+	})
+	if want := []int{7, 6, 5, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("DescendRange = %v, want %v", got, want)
+	}
+}